@@ -0,0 +1,29 @@
+package config
+
+import (
+	"bytes"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type yamlCodec struct{}
+
+func (yamlCodec) Encode(v any) ([]byte, error) { return yaml.Marshal(v) }
+
+func (yamlCodec) Decode(data []byte, v any) error { return yaml.Unmarshal(data, v) }
+
+type tomlCodec struct{}
+
+func (tomlCodec) Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (tomlCodec) Decode(data []byte, v any) error {
+	_, err := toml.Decode(string(data), v)
+	return err
+}
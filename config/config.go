@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"golang-question/errorx"
 	"reflect"
 	"sync"
@@ -12,12 +13,63 @@ type Manager[T any] interface {
 	OnChange(func(T)) (cancel func())
 	Watch() Manager[T]
 	InitData(T) Manager[T]
+	// Async 把 OnChange 回调的分发方式切换为通过 workers 个 goroutine 组成的
+	// worker 池异步执行，而不是在 Update 内同步调用。
+	Async(workers int) Manager[T]
+	// Errors 返回一个 channel，OnChange 回调内部发生的 panic 会以
+	// errorx.Error（ErrTypeInternal）的形式出现在这里。
+	Errors() <-chan errorx.Error
+	// WithValidator 注册一个校验函数，Update/InitData 写入新值之前都会跑一遍
+	// 所有已注册的 validator，按注册顺序执行，任意一个失败就拒绝写入。
+	WithValidator(Validator[T]) Manager[T]
+	// WithMigrator 注册一个迁移函数，在校验全部通过之后按注册顺序运行，
+	// 可以用来补全默认字段或者做版本迁移，返回值才是最终写入的值。
+	WithMigrator(Migrator[T]) Manager[T]
+	// History 返回最近 n 条历史快照，按从旧到新排列；n <= 0 返回全部（最多
+	// WithHistorySize 设置的容量，默认 16 条）。
+	History(n int) []Snapshot[T]
+	// Rollback 把当前值恢复成 version 对应的历史快照。恢复前只会重新跑一遍
+	// 校验（不会重新跑迁移），成功后把快照里的原值原样作为一次新的写入记录
+	// （带一个新的版本号）——如果这里也跑迁移，像"版本号自增"这种非幂等的
+	// migrator 会在回滚时把已经落盘的历史值二次修改，版本 V 的快照就不再是
+	// V 当时真正生效过的值了。
+	Rollback(version uint64) errorx.Error
+	// WithHistorySize 设置历史快照环形缓冲区的容量，默认 16。
+	WithHistorySize(size int) Manager[T]
+}
+
+const (
+	// ErrCodeHistoryVersion 请求的历史版本不存在
+	ErrCodeHistoryVersion = 2006
+)
+
+// LocalManager 在 Manager[T] 之上暴露本地后端特有的能力：停止 Async 启动的
+// worker 池。和 FileManager[T]/EtcdManager[T] 一样，链式方法的返回类型改成
+// LocalManager[T] 而不是嵌入 Manager[T]，这样 .WithValidator(...).Watch() 这
+// 样的链式调用之后才能继续拿到 Close。
+type LocalManager[T any] interface {
+	Get() T
+	Update(T) errorx.Error
+	OnChange(func(T)) (cancel func())
+	Watch() LocalManager[T]
+	InitData(T) LocalManager[T]
+	Async(workers int) LocalManager[T]
+	Errors() <-chan errorx.Error
+	WithValidator(Validator[T]) LocalManager[T]
+	WithMigrator(Migrator[T]) LocalManager[T]
+	History(n int) []Snapshot[T]
+	Rollback(version uint64) errorx.Error
+	WithHistorySize(size int) LocalManager[T]
+	// Close 停止 Async 启动的 worker 池 goroutine。
+	Close() error
 }
 
 type localManager[T any] struct {
-	data     T
-	mu       sync.RWMutex
-	callback func(T)
+	data T
+	mu   sync.RWMutex
+	subs *subscribers[T]
+	pipe *pipeline[T]
+	hist *history[T]
 }
 
 // Get 从本地管理器中获取当前存储的数据。
@@ -29,47 +81,110 @@ func (m *localManager[T]) Get() T {
 	return m.data
 }
 
-// Update 用于更新 localManager 中的数据。
-// 参数 newData 是新的数据。
-// 如果提供了回调函数 callback，则在更新数据后调用该函数，并将 newData 作为参数传入。
-// 如果操作成功，返回 nil；否则返回 errorx.Error 类型的错误。
+// Update 先跑校验/迁移管线，通过之后才更新 localManager 中的数据。
+// 如果任意一个 validator 拒绝了 newData，存储的值保持不变，返回对应的
+// errorx.Error（ErrTypeInvalid）。
 func (m *localManager[T]) Update(newData T) errorx.Error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.data = newData
-	if m.callback != nil {
-		m.callback(newData)
+	return m.apply(newData, "update", true)
+}
+
+// Rollback 把当前值恢复成 version 对应的历史快照，见 Manager[T] 接口说明。
+// 只重新跑校验，不会再跑一遍迁移，因此写回的就是快照里原封不动的值。
+func (m *localManager[T]) Rollback(version uint64) errorx.Error {
+	snap, ok := m.hist.find(version)
+	if !ok {
+		return errorx.Typedf(errorx.ErrTypeNotFound, ErrCodeHistoryVersion, "config: no snapshot for version %d", version)
 	}
-	return nil
+	return m.apply(snap.Value, "rollback", false)
 }
 
-// OnChange 为localManager类型的方法，它接收一个类型为T的回调函数作为参数。
-// 当localManager管理的数据发生变化时，会自动调用该回调函数。
+// apply 是 Update 和 Rollback 共用的核心逻辑：通过之后替换当前值、记录一条
+// 新的历史快照，再把最终值分发给所有通过 OnChange 注册的订阅者。订阅者列表
+// 会在持有锁的情况下快照，真正调用回调时已经释放了锁，因此一个慢回调或者
+// panic 的回调不会拖住后续的 Get/Update。
 //
-// 参数：
-//     callback: 当数据发生变化时调用的回调函数，接收一个类型为T的参数。
-//
-// 返回值：
-//     返回一个取消函数，调用该函数可以取消之前设置的回调函数。
-//
-// 注意：
-//     当调用取消函数后，即使数据发生变化，之前设置的回调函数也不会再被调用。
-func (m *localManager[T]) OnChange(callback func(T)) (cancel func()) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.callback = callback
-	return func() {
-		m.mu.Lock()
-		defer m.mu.Unlock()
-		m.callback = nil
+// runMigrators 为 true 时和 Update 一样跑完整的校验/迁移管线；为 false 时
+// （Rollback 用这个）只跑校验，newData 本身就是最终要写入的值——历史快照
+// 里的值已经在第一次写入时跑过迁移了，回滚不应该再跑一遍，否则像版本号
+// 自增这类非幂等的 migrator 会把快照值再改一次。
+func (m *localManager[T]) apply(newData T, source string, runMigrators bool) errorx.Error {
+	m.mu.RLock()
+	old := m.data
+	m.mu.RUnlock()
+
+	result := newData
+	if runMigrators {
+		r, err := m.pipe.run(old, newData)
+		if err != nil {
+			return err
+		}
+		result = r
+	} else if err := m.pipe.validate(newData); err != nil {
+		return err
 	}
+
+	m.mu.Lock()
+	m.data = result
+	m.mu.Unlock()
+
+	m.hist.record(result, source)
+	m.subs.notify(result)
+	return nil
+}
+
+// History 返回最近 n 条历史快照，见 Manager[T] 接口说明。
+func (m *localManager[T]) History(n int) []Snapshot[T] {
+	return m.hist.list(n)
+}
+
+// WithHistorySize 设置历史快照环形缓冲区的容量。
+func (m *localManager[T]) WithHistorySize(size int) LocalManager[T] {
+	m.hist.setSize(size)
+	return m
+}
+
+// OnChange 注册一个回调，数据发生变化时会被调用。
+// 与旧版本不同，现在可以注册任意多个回调：每次调用都会得到一个只属于它自己的
+// cancel 函数，调用 cancel 只会移除这一个回调，不会影响其它订阅者。
+func (m *localManager[T]) OnChange(callback func(T)) (cancel func()) {
+	return m.subs.add(callback)
 }
 
 // Watch 返回当前localManager对象本身，实现Manager接口
-func (m *localManager[T]) Watch() Manager[T] { 
+func (m *localManager[T]) Watch() LocalManager[T] {
+	return m
+}
+
+// Async 把 OnChange 回调的分发方式切换为异步的 worker 池，默认是同步调用。
+func (m *localManager[T]) Async(workers int) LocalManager[T] {
+	m.subs.setAsync(workers)
+	return m
+}
+
+// Errors 返回 OnChange 回调 panic 时产生的错误 channel。
+func (m *localManager[T]) Errors() <-chan errorx.Error {
+	return m.subs.errors()
+}
+
+// WithValidator 注册一个校验函数，见 Manager[T] 接口说明。
+func (m *localManager[T]) WithValidator(v Validator[T]) LocalManager[T] {
+	m.pipe.addValidator(v)
+	return m
+}
+
+// WithMigrator 注册一个迁移函数，见 Manager[T] 接口说明。
+func (m *localManager[T]) WithMigrator(mig Migrator[T]) LocalManager[T] {
+	m.pipe.addMigrator(mig)
 	return m
 }
 
+// Close 停止 Async 启动的 worker 池 goroutine。localManager 没有后台
+// goroutine，只有 Async 之后才会有 worker 需要停止，所以 Close 总是安全的。
+func (m *localManager[T]) Close() error {
+	m.subs.close()
+	return nil
+}
+
 // isZeroValue 函数用于判断给定的值是否是其类型的零值。
 //
 // 参数：
@@ -81,15 +196,29 @@ func isZeroValue[T any](value T) bool {
 	return reflect.DeepEqual(value, reflect.Zero(reflect.TypeOf(value)).Interface())
 }
 
-// InitData 使用初始数据初始化localManager的数据
-// 如果localManager的数据是零值，则将localManager的数据设置为initialData
-// 返回初始化后的localManager实例
-func (m *localManager[T]) InitData(initialData T) Manager[T] {
+// InitData 使用初始数据初始化localManager的数据。
+// 如果localManager的数据是零值，则将localManager的数据设置为initialData，
+// 这个值同样要经过校验/迁移管线 —— 一个不合法的默认配置应该在启动时就
+// panic，而不是被悄悄地接受下来。
+// 返回初始化后的localManager实例。
+func (m *localManager[T]) InitData(initialData T) LocalManager[T] {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-	if isZeroValue(m.data) {
-		m.data = initialData
+	if !isZeroValue(m.data) {
+		m.mu.Unlock()
+		return m
+	}
+	old := m.data
+	m.mu.Unlock()
+
+	result, err := m.pipe.run(old, initialData)
+	if err != nil {
+		panic(fmt.Sprintf("config: invalid initial data: %+v", err))
 	}
+
+	m.mu.Lock()
+	m.data = result
+	m.mu.Unlock()
+	m.hist.record(result, "init")
 	return m
 }
 
@@ -98,13 +227,11 @@ func (m *localManager[T]) InitData(initialData T) Manager[T] {
 // - T: 泛型类型，代表管理器将管理的元素类型
 //
 // 返回值：
-// - Manager[T]: 返回本地管理器的实例
-func Local[T any]() Manager[T] {
-	return &localManager[T]{}
-}
-
-// Etcd implementation remains as a TODO
-func Etcd[T any]() Manager[T] {
-	//TODO: implement
-	return nil
+// - LocalManager[T]: 返回本地管理器的实例
+func Local[T any]() LocalManager[T] {
+	return &localManager[T]{
+		subs: newSubscribers[T](),
+		pipe: newPipeline[T](),
+		hist: newHistory[T](defaultHistorySize),
+	}
 }
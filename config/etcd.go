@@ -0,0 +1,477 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang-question/errorx"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	// ErrCodeEtcdEncode 编码配置数据失败
+	ErrCodeEtcdEncode = 2001
+	// ErrCodeEtcdDecode 解码配置数据失败
+	ErrCodeEtcdDecode = 2002
+	// ErrCodeEtcdCAS 基于 mod_revision 的 CAS 写入失败
+	ErrCodeEtcdCAS = 2003
+	// ErrCodeEtcdUnavailable etcd 连接或租约不可用
+	ErrCodeEtcdUnavailable = 2004
+)
+
+// Codec 定义了配置数据的序列化/反序列化方式，Etcd 后端默认使用 JSON 编码，
+// 可以通过 WithCodec 替换为 YAML/TOML 等其它格式。
+type Codec interface {
+	Encode(v any) ([]byte, error)
+	Decode(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Decode(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// EtcdOption 用于配置 etcdManager 的可选参数。
+type EtcdOption func(*etcdOptions)
+
+type etcdOptions struct {
+	codec      Codec
+	prefix     string
+	defaultTTL time.Duration
+}
+
+func defaultEtcdOptions() *etcdOptions {
+	return &etcdOptions{
+		codec:      jsonCodec{},
+		defaultTTL: 10 * time.Second,
+	}
+}
+
+// WithCodec 设置 etcd 配置数据的编解码器，默认为 JSON。
+func WithCodec(codec Codec) EtcdOption {
+	return func(o *etcdOptions) { o.codec = codec }
+}
+
+// WithPrefix 为 etcd key 增加一个前缀，便于命名空间隔离。
+func WithPrefix(prefix string) EtcdOption {
+	return func(o *etcdOptions) { o.prefix = prefix }
+}
+
+// WithDefaultTTL 设置 keepalive 租约的默认 TTL，watcher 通过它判断自己是否还持有有效的 session。
+func WithDefaultTTL(ttl time.Duration) EtcdOption {
+	return func(o *etcdOptions) { o.defaultTTL = ttl }
+}
+
+// envelope 是写入 etcd 的实际格式：除了配置值本身，还携带 version/at/source
+// 元数据。任何进程只要 watch 到这个 key，都能从 envelope 里重建出一致的版本
+// 号和来源，Rollback 才能跨进程工作，而不仅仅是在发起写入的那个进程里。
+type envelope[T any] struct {
+	Version uint64    `json:"version"`
+	At      time.Time `json:"at"`
+	Source  string    `json:"source"`
+	Value   T         `json:"value"`
+}
+
+// etcdManager 是 EtcdManager[T] 基于 etcd v3 的实现。
+// 本地缓存的数据和 mod_revision 由 mu 保护，Update 使用缓存的 revision 做 CAS 写入，
+// Watch 启动的后台 goroutine 负责保持缓存与 etcd 同步。
+type etcdManager[T any] struct {
+	client *clientv3.Client
+	key    string
+	opts   *etcdOptions
+
+	mu       sync.RWMutex
+	data     T
+	revision int64
+	subs     *subscribers[T]
+	pipe     *pipeline[T]
+	hist     *history[T]
+
+	leaseID clientv3.LeaseID
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// EtcdManager 在 Manager[T] 之上暴露 etcd 后端特有的能力：停止 Watch 启动的
+// 后台 goroutine，以及读出当前持有的租约 ID 供调用方判断 watcher 是否还在
+// 正常续约。其它后端没有对应语义，所以没有放进 Manager[T]。
+//
+// 和 FileManager[T] 一样，链式方法（Watch/InitData/...）的返回类型改成
+// EtcdManager[T] 而不是嵌入 Manager[T]，这样才能在
+// .WithValidator(...).Watch() 这样的链式调用之后继续拿到 Close/LeaseID。
+type EtcdManager[T any] interface {
+	Get() T
+	Update(T) errorx.Error
+	OnChange(func(T)) (cancel func())
+	Watch() EtcdManager[T]
+	InitData(T) EtcdManager[T]
+	Async(workers int) EtcdManager[T]
+	Errors() <-chan errorx.Error
+	WithValidator(Validator[T]) EtcdManager[T]
+	WithMigrator(Migrator[T]) EtcdManager[T]
+	History(n int) []Snapshot[T]
+	Rollback(version uint64) errorx.Error
+	WithHistorySize(size int) EtcdManager[T]
+	// LeaseID 返回当前持有的租约 ID；在第一次 Grant 成功之前，或者租约已经
+	// 失效、正在被 keepLease 重新申请的窗口期内，返回 0。
+	LeaseID() clientv3.LeaseID
+	// Close 停止 keepLease/watchLoop 两个后台 goroutine，并撤销当前持有的
+	// 租约。Close 之后这个 EtcdManager 不应该再被使用。
+	Close() error
+}
+
+// Etcd 创建一个基于 etcd v3 的配置管理器，返回 EtcdManager[T]。它的链式方法
+// 返回 EtcdManager[T] 而不是 Manager[T]，所以不满足 Manager[T] 接口，不能赋给
+// Manager[T] 变量。
+// client 由调用方创建和维护生命周期，key 是配置存储的键（会与 WithPrefix 拼接），
+// opts 可用于自定义编解码器、key 前缀和租约 TTL。
+func Etcd[T any](client *clientv3.Client, key string, opts ...EtcdOption) EtcdManager[T] {
+	o := defaultEtcdOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &etcdManager[T]{
+		client: client,
+		key:    o.prefix + key,
+		opts:   o,
+		subs:   newSubscribers[T](),
+		pipe:   newPipeline[T](),
+		hist:   newHistory[T](defaultHistorySize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Get 返回本地缓存的、最近一次从 etcd 解码得到的值。
+func (m *etcdManager[T]) Get() T {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.data
+}
+
+// Update 先跑校验/迁移管线，通过之后基于最近一次读取到的 mod_revision 执行
+// CAS 写入。
+// 如果任意一个 validator 拒绝了 newData，返回 ErrTypeInvalid 且不会碰 etcd；
+// 如果 revision 已经被其他写入者推进，返回 ErrTypeConflict；
+// 如果提交 CAS 事务时出错（例如连接不可用），返回 ErrTypeUnavailable；
+// 这里不检查 keepalive 租约的状态。
+func (m *etcdManager[T]) Update(newData T) errorx.Error {
+	return m.apply(newData, "update", true)
+}
+
+// Rollback 把当前值恢复成 version 对应的历史快照，见 Manager[T] 接口说明。
+// 只能回滚到这个进程的历史缓冲区里还保留着的版本 —— 缓冲区会随着 Watch 观察
+// 到的每一次远端变更（见 resync/watchLoop）增长，所以只要各进程都开着
+// Watch，版本号和 Source 就能在进程之间保持一致。只重新跑校验，不会再跑一遍
+// 迁移，写回的就是快照里原封不动的值。
+func (m *etcdManager[T]) Rollback(version uint64) errorx.Error {
+	snap, ok := m.hist.find(version)
+	if !ok {
+		return errorx.Typedf(errorx.ErrTypeNotFound, ErrCodeHistoryVersion, "config: no snapshot for version %d", version)
+	}
+	return m.apply(snap.Value, "rollback", false)
+}
+
+// History 返回最近 n 条历史快照，见 Manager[T] 接口说明。
+func (m *etcdManager[T]) History(n int) []Snapshot[T] {
+	return m.hist.list(n)
+}
+
+// WithHistorySize 设置历史快照环形缓冲区的容量。
+func (m *etcdManager[T]) WithHistorySize(size int) EtcdManager[T] {
+	m.hist.setSize(size)
+	return m
+}
+
+// apply 是 Update 和 Rollback 共用的核心逻辑。版本号在提交 CAS 写入之前就
+// reserve 出来，因为它要和值一起编码进 envelope；如果 CAS 失败，这个版本号
+// 就被跳过不用，不影响正确性，只是不连续。
+//
+// runMigrators 为 true 时（Update）跑完整的校验/迁移管线；为 false 时
+// （Rollback）只跑校验——历史快照里的值已经在第一次写入时跑过迁移了，再跑
+// 一遍会让非幂等的 migrator（比如版本号自增）把快照值二次修改。
+func (m *etcdManager[T]) apply(newData T, source string, runMigrators bool) errorx.Error {
+	m.mu.RLock()
+	old := m.data
+	rev := m.revision
+	m.mu.RUnlock()
+
+	result := newData
+	if runMigrators {
+		r, err := m.pipe.run(old, newData)
+		if err != nil {
+			return err
+		}
+		result = r
+	} else if err := m.pipe.validate(newData); err != nil {
+		return err
+	}
+
+	version := m.hist.reserve()
+	at := time.Now()
+	payload, encErr := m.opts.codec.Encode(envelope[T]{Version: version, At: at, Source: source, Value: result})
+	if encErr != nil {
+		return errorx.Typedf(errorx.ErrTypeInvalid, ErrCodeEtcdEncode, "config: encode %s: %v", m.key, encErr)
+	}
+
+	txn := m.client.Txn(m.ctx).
+		If(clientv3.Compare(clientv3.ModRevision(m.key), "=", rev)).
+		Then(clientv3.OpPut(m.key, string(payload))).
+		Else(clientv3.OpGet(m.key))
+	resp, txnErr := txn.Commit()
+	if txnErr != nil {
+		return errorx.Typedf(errorx.ErrTypeUnavailable, ErrCodeEtcdUnavailable, "config: etcd unavailable for %s: %v", m.key, txnErr)
+	}
+	if !resp.Succeeded {
+		return errorx.Typedf(errorx.ErrTypeConflict, ErrCodeEtcdCAS, "config: %s was modified concurrently, retry with latest value", m.key)
+	}
+
+	m.mu.Lock()
+	m.data = result
+	m.revision = resp.Header.Revision
+	m.mu.Unlock()
+
+	m.hist.append(version, result, source, at)
+	m.subs.notify(result)
+	return nil
+}
+
+// OnChange 注册一个回调，在 Watch 检测到远端变更或本地 Update 成功时触发。
+// 可以注册任意多个回调，返回的 cancel 只移除这一个。
+func (m *etcdManager[T]) OnChange(callback func(T)) (cancel func()) {
+	return m.subs.add(callback)
+}
+
+// Async 把 OnChange 回调的分发方式切换为异步的 worker 池，默认是同步调用。
+func (m *etcdManager[T]) Async(workers int) EtcdManager[T] {
+	m.subs.setAsync(workers)
+	return m
+}
+
+// Errors 返回 OnChange 回调 panic 时产生的错误 channel。
+func (m *etcdManager[T]) Errors() <-chan errorx.Error {
+	return m.subs.errors()
+}
+
+// WithValidator 注册一个校验函数，见 Manager[T] 接口说明。
+func (m *etcdManager[T]) WithValidator(v Validator[T]) EtcdManager[T] {
+	m.pipe.addValidator(v)
+	return m
+}
+
+// WithMigrator 注册一个迁移函数，见 Manager[T] 接口说明。
+func (m *etcdManager[T]) WithMigrator(mig Migrator[T]) EtcdManager[T] {
+	m.pipe.addMigrator(mig)
+	return m
+}
+
+// Watch 并行启动两个后台 goroutine：一个维持带 keepalive 的租约（当前租约 ID
+// 通过 LeaseID 暴露），另一个从当前 revision 开始监听 key 的变更，解码后原子
+// 替换缓存并触发 OnChange。
+// 一旦 watch 通道因连接问题关闭，会重新做一次全量 Get 以拿到最新 revision，
+// 避免从一个过期的 revision 继续监听而漏掉中间的变更。
+func (m *etcdManager[T]) Watch() EtcdManager[T] {
+	go m.keepLease()
+	go m.watchLoop()
+	return m
+}
+
+// LeaseID 返回当前持有的 keepalive 租约 ID，见 EtcdManager[T] 接口说明。
+func (m *etcdManager[T]) LeaseID() clientv3.LeaseID {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.leaseID
+}
+
+// Close 停止 keepLease/watchLoop 两个后台 goroutine、Async 启动的 worker
+// 池，并撤销当前持有的租约，见 EtcdManager[T] 接口说明。多次调用是安全的，
+// 只有第一次会真正生效。
+func (m *etcdManager[T]) Close() error {
+	m.cancel()
+	m.subs.close()
+	m.mu.Lock()
+	lease := m.leaseID
+	m.leaseID = 0
+	m.mu.Unlock()
+	if lease == 0 {
+		return nil
+	}
+	_, err := m.client.Revoke(context.Background(), lease)
+	return err
+}
+
+// keepLease 维持一个带 TTL 的租约并保持续约，调用方可以通过 LeaseID 是否为 0
+// 判断当前是否持有有效租约（watchLoop 本身不读取它）；租约失效或续约出错时
+// 会自动重新申请。
+func (m *etcdManager[T]) keepLease() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		default:
+		}
+
+		lease, err := m.client.Grant(m.ctx, int64(m.opts.defaultTTL.Seconds()))
+		if err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+		m.mu.Lock()
+		m.leaseID = lease.ID
+		m.mu.Unlock()
+
+		keepAlive, err := m.client.KeepAlive(m.ctx, lease.ID)
+		if err != nil {
+			m.mu.Lock()
+			m.leaseID = 0
+			m.mu.Unlock()
+			time.Sleep(time.Second)
+			continue
+		}
+		for range keepAlive {
+			// 消费 keepalive 响应，保持租约存活；channel 关闭意味着租约失效或连接断开。
+		}
+		// keepAlive 通道已经关闭：租约要么已经失效、要么这次续约流连接断开了，
+		// 在重新 Grant 出一个新租约之前，LeaseID() 应该如实反映"当前没有有效
+		// 租约"，而不是继续返回这个已经不可信的旧 ID。
+		m.mu.Lock()
+		m.leaseID = 0
+		m.mu.Unlock()
+
+		select {
+		case <-m.ctx.Done():
+			return
+		default:
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// watchLoop 是 Watch 的核心循环：全量同步一次，然后从同步到的 revision 继续监听，
+// watch 通道异常关闭时重新全量同步，避免漏事件或死循环重放旧数据。
+func (m *etcdManager[T]) watchLoop() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		default:
+		}
+
+		rev, err := m.resync()
+		if err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		watchCh := m.client.Watch(m.ctx, m.key, clientv3.WithRev(rev+1))
+		for resp := range watchCh {
+			if resp.Canceled {
+				break
+			}
+			for _, ev := range resp.Events {
+				if ev.Type != clientv3.EventTypePut {
+					continue
+				}
+				var env envelope[T]
+				if err := m.opts.codec.Decode(ev.Kv.Value, &env); err != nil {
+					continue
+				}
+				m.mu.Lock()
+				m.data = env.Value
+				m.revision = ev.Kv.ModRevision
+				m.mu.Unlock()
+				m.hist.recordIfNew(env.Version, env.Value, env.Source, env.At)
+				m.subs.notify(env.Value)
+			}
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
+// resync 对 key 做一次全量 Get，把结果作为新的缓存基准并返回其 revision，
+// 供 watchLoop 从该 revision 之后继续监听。key 不存在时缓存的 mod_revision
+// 置为 0（etcd 对不存在的 key 比较 ModRevision 时取 0），返回值则是这次 Get
+// 的 header revision。
+func (m *etcdManager[T]) resync() (int64, error) {
+	resp, err := m.client.Get(m.ctx, m.key)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		m.mu.Lock()
+		m.revision = 0
+		m.mu.Unlock()
+		return resp.Header.Revision, nil
+	}
+
+	var env envelope[T]
+	if err := m.opts.codec.Decode(resp.Kvs[0].Value, &env); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	m.data = env.Value
+	m.revision = resp.Kvs[0].ModRevision
+	m.mu.Unlock()
+	m.hist.recordIfNew(env.Version, env.Value, env.Source, env.At)
+	return resp.Kvs[0].ModRevision, nil
+}
+
+// InitData 如果 etcd 中尚未存在该 key，则写入 initialData 作为初始值；
+// 如果已经存在，则把现有值解码加载到本地缓存，语义上与 localManager.InitData
+// 的"仅在为空时生效"保持一致，但判断依据是远端是否已有数据而非本地零值。
+// initialData 同样要经过校验/迁移管线，校验失败会直接 panic —— 一个不合法的
+// 默认配置应该在启动时就暴露出来，而不是被写进 etcd。
+func (m *etcdManager[T]) InitData(initialData T) EtcdManager[T] {
+	resp, err := m.client.Get(m.ctx, m.key)
+	if err != nil {
+		return m
+	}
+
+	if len(resp.Kvs) == 0 {
+		var zero T
+		validated, pipeErr := m.pipe.run(zero, initialData)
+		if pipeErr != nil {
+			panic(fmt.Sprintf("config: invalid initial data: %+v", pipeErr))
+		}
+
+		version := m.hist.reserve()
+		at := time.Now()
+		payload, encErr := m.opts.codec.Encode(envelope[T]{Version: version, At: at, Source: "init", Value: validated})
+		if encErr != nil {
+			return m
+		}
+		putResp, putErr := m.client.Put(m.ctx, m.key, string(payload))
+		if putErr != nil {
+			return m
+		}
+		m.mu.Lock()
+		m.data = validated
+		m.revision = putResp.Header.Revision
+		m.mu.Unlock()
+		m.hist.append(version, validated, "init", at)
+		return m
+	}
+
+	var env envelope[T]
+	if err := m.opts.codec.Decode(resp.Kvs[0].Value, &env); err != nil {
+		return m
+	}
+	m.mu.Lock()
+	m.data = env.Value
+	m.revision = resp.Kvs[0].ModRevision
+	m.mu.Unlock()
+	m.hist.recordIfNew(env.Version, env.Value, env.Source, env.At)
+	return m
+}
@@ -0,0 +1,453 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-question/errorx"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// ErrCodeFileRead 读取配置文件失败
+	ErrCodeFileRead = 3001
+	// ErrCodeFileDecode 解码配置文件内容失败
+	ErrCodeFileDecode = 3002
+	// ErrCodeFileEncode 编码配置内容失败
+	ErrCodeFileEncode = 3003
+	// ErrCodeFileWrite 写入配置文件失败
+	ErrCodeFileWrite = 3004
+	// ErrCodeFileWatch 创建或维护 fsnotify watcher 失败
+	ErrCodeFileWatch = 3005
+)
+
+// FileOption 用于配置 fileManager 的可选参数。
+type FileOption func(*fileOptions)
+
+type fileOptions struct {
+	codec    Codec
+	debounce time.Duration
+}
+
+func defaultFileOptions(path string) *fileOptions {
+	return &fileOptions{
+		codec:    codecForExt(path),
+		debounce: 200 * time.Millisecond,
+	}
+}
+
+// WithFileCodec 覆盖根据文件扩展名推断出的编解码器。
+func WithFileCodec(codec Codec) FileOption {
+	return func(o *fileOptions) { o.codec = codec }
+}
+
+// WithDebounce 设置文件变更事件的去抖窗口，默认 200ms，用于把编辑器一次保存
+// 触发的多个文件系统事件合并成一次重载。
+func WithDebounce(d time.Duration) FileOption {
+	return func(o *fileOptions) { o.debounce = d }
+}
+
+// codecForExt 根据文件扩展名选择默认编解码器：.yaml/.yml 用 YAML，.toml 用
+// TOML，其余（包括 .json）默认按 JSON 处理。
+func codecForExt(path string) Codec {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yamlCodec{}
+	case ".toml":
+		return tomlCodec{}
+	default:
+		return jsonCodec{}
+	}
+}
+
+// FileManager 在 Manager[T] 之上暴露文件后端特有的能力：注册重载失败时的错误
+// 钩子，以及停止后台 watcher。其它后端没有对应语义，所以没有放进 Manager[T]。
+//
+// FileManager[T] 没有直接嵌入 Manager[T]：如果 Watch/InitData 等链式方法继续
+// 声明为返回 Manager[T]，一旦链上调用了它们，返回值就退化成 Manager[T]，
+// OnError/Close 会从方法集里消失，而 main.go 里展示的用法——一路
+// .WithValidator(...).Watch().InitData(...) 链下去——恰恰是这个包里唯一示范
+// 过的调用方式。所以这里把同名的链式方法签名改成返回 FileManager[T]，
+// 其余方法签名和 Manager[T] 保持一致。因为链式方法的返回类型不同，
+// *fileManager[T] 只满足 FileManager[T]，不满足 Manager[T]，不能赋给
+// Manager[T] 变量。
+type FileManager[T any] interface {
+	Get() T
+	Update(T) errorx.Error
+	OnChange(func(T)) (cancel func())
+	Watch() FileManager[T]
+	InitData(T) FileManager[T]
+	Async(workers int) FileManager[T]
+	Errors() <-chan errorx.Error
+	WithValidator(Validator[T]) FileManager[T]
+	WithMigrator(Migrator[T]) FileManager[T]
+	History(n int) []Snapshot[T]
+	Rollback(version uint64) errorx.Error
+	WithHistorySize(size int) FileManager[T]
+	// OnError 注册一个钩子，后台重载（文件变更触发）失败时会把 errorx.Error
+	// 传给它，而不是被静默丢弃。
+	OnError(func(errorx.Error)) FileManager[T]
+	// Close 停止 watcher goroutine。
+	Close() error
+}
+
+// fileManager 是 FileManager[T] 基于本地文件的实现。
+type fileManager[T any] struct {
+	path string
+	opts *fileOptions
+
+	mu      sync.RWMutex
+	data    T
+	onError func(errorx.Error)
+	watcher *fsnotify.Watcher
+
+	subs *subscribers[T]
+	pipe *pipeline[T]
+	hist *history[T]
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// File 创建一个以本地文件为存储的配置管理器，返回 FileManager[T]（不满足
+// Manager[T] 接口，见 FileManager[T] 的说明）。
+// 构造时会立即读取并解码一次 path：如果文件不存在，留给调用方通过 InitData
+// 写入默认值；如果文件存在但解码失败，说明配置本身已经损坏，直接 panic。
+func File[T any](path string, opts ...FileOption) FileManager[T] {
+	o := defaultFileOptions(path)
+	for _, opt := range opts {
+		opt(o)
+	}
+	m := &fileManager[T]{
+		path:    path,
+		opts:    o,
+		subs:    newSubscribers[T](),
+		pipe:    newPipeline[T](),
+		hist:    newHistory[T](defaultHistorySize),
+		closeCh: make(chan struct{}),
+	}
+	if err := m.reload(); err != nil && !errorx.IsType(err, errorx.ErrTypeNotFound) {
+		panic(fmt.Sprintf("config: %+v", err))
+	}
+	return m
+}
+
+// Get 返回最近一次成功加载到的配置。
+func (m *fileManager[T]) Get() T {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.data
+}
+
+// Update 跑完校验/迁移管线之后，把结果原子地写回文件（先写临时文件再
+// rename），这样并发的外部 watcher 看到的始终是完整的一份内容，而不会读到
+// 写了一半的文件。写入成功后更新缓存、记录一条历史快照并通知订阅者；Watch
+// 之后看到这次自己写入触发的文件事件只会重新解码出同样的值，属于预期内的
+// 重复通知。
+func (m *fileManager[T]) Update(newData T) errorx.Error {
+	return m.apply(newData, "update", true)
+}
+
+// Rollback 把当前值恢复成 version 对应的历史快照，见 Manager[T] 接口说明。
+// 只重新跑校验，不会再跑一遍迁移，写回的就是快照里原封不动的值。
+func (m *fileManager[T]) Rollback(version uint64) errorx.Error {
+	snap, ok := m.hist.find(version)
+	if !ok {
+		return errorx.Typedf(errorx.ErrTypeNotFound, ErrCodeHistoryVersion, "config: no snapshot for version %d", version)
+	}
+	return m.apply(snap.Value, "rollback", false)
+}
+
+// History 返回最近 n 条历史快照，见 Manager[T] 接口说明。
+func (m *fileManager[T]) History(n int) []Snapshot[T] {
+	return m.hist.list(n)
+}
+
+// WithHistorySize 设置历史快照环形缓冲区的容量。
+func (m *fileManager[T]) WithHistorySize(size int) FileManager[T] {
+	m.hist.setSize(size)
+	return m
+}
+
+// apply 是 Update 和 Rollback 共用的核心逻辑。runMigrators 为 true 时
+// （Update）跑完整的校验/迁移管线；为 false 时（Rollback）只跑校验——历史
+// 快照里的值已经在第一次写入时跑过迁移了，再跑一遍会让非幂等的 migrator
+// （比如版本号自增）把快照值二次修改。
+func (m *fileManager[T]) apply(newData T, source string, runMigrators bool) errorx.Error {
+	m.mu.RLock()
+	old := m.data
+	m.mu.RUnlock()
+
+	result := newData
+	if runMigrators {
+		r, err := m.pipe.run(old, newData)
+		if err != nil {
+			return err
+		}
+		result = r
+	} else if err := m.pipe.validate(newData); err != nil {
+		return err
+	}
+
+	payload, encErr := m.opts.codec.Encode(result)
+	if encErr != nil {
+		return errorx.Typedf(errorx.ErrTypeInvalid, ErrCodeFileEncode, "config: encode %s: %v", m.path, encErr)
+	}
+	if writeErr := writeFileAtomic(m.path, payload); writeErr != nil {
+		return errorx.Typedf(errorx.ErrTypeInternal, ErrCodeFileWrite, "config: write %s: %v", m.path, writeErr)
+	}
+
+	m.mu.Lock()
+	m.data = result
+	m.mu.Unlock()
+
+	version := m.hist.record(result, source)
+	m.writeHistorySnapshot(version, result)
+	m.subs.notify(result)
+	return nil
+}
+
+// OnChange 注册一个回调，数据发生变化（无论是本地 Update 还是 Watch 检测到
+// 的外部修改）时会被调用。
+func (m *fileManager[T]) OnChange(callback func(T)) (cancel func()) {
+	return m.subs.add(callback)
+}
+
+// Async 把 OnChange 回调的分发方式切换为异步的 worker 池，默认是同步调用。
+func (m *fileManager[T]) Async(workers int) FileManager[T] {
+	m.subs.setAsync(workers)
+	return m
+}
+
+// Errors 返回 OnChange 回调 panic 时产生的错误 channel。
+func (m *fileManager[T]) Errors() <-chan errorx.Error {
+	return m.subs.errors()
+}
+
+// WithValidator 注册一个校验函数，见 Manager[T] 接口说明。
+func (m *fileManager[T]) WithValidator(v Validator[T]) FileManager[T] {
+	m.pipe.addValidator(v)
+	return m
+}
+
+// WithMigrator 注册一个迁移函数，见 Manager[T] 接口说明。
+func (m *fileManager[T]) WithMigrator(mig Migrator[T]) FileManager[T] {
+	m.pipe.addMigrator(mig)
+	return m
+}
+
+// InitData 只在当前值还是零值时生效：把 initialData 写入文件并作为当前值。
+// initialData 同样要经过校验/迁移管线，失败则直接 panic —— 语义与
+// localManager.InitData 保持一致。
+func (m *fileManager[T]) InitData(initialData T) FileManager[T] {
+	m.mu.RLock()
+	zero := isZeroValue(m.data)
+	old := m.data
+	m.mu.RUnlock()
+	if !zero {
+		return m
+	}
+
+	result, err := m.pipe.run(old, initialData)
+	if err != nil {
+		panic(fmt.Sprintf("config: invalid initial data: %+v", err))
+	}
+
+	payload, encErr := m.opts.codec.Encode(result)
+	if encErr != nil {
+		panic(fmt.Sprintf("config: encode initial data for %s: %v", m.path, encErr))
+	}
+	if writeErr := writeFileAtomic(m.path, payload); writeErr != nil {
+		panic(fmt.Sprintf("config: write initial data to %s: %v", m.path, writeErr))
+	}
+
+	m.mu.Lock()
+	m.data = result
+	m.mu.Unlock()
+
+	version := m.hist.record(result, "init")
+	m.writeHistorySnapshot(version, result)
+	return m
+}
+
+// OnError 注册重载失败时的错误钩子。
+func (m *fileManager[T]) OnError(fn func(errorx.Error)) FileManager[T] {
+	m.mu.Lock()
+	m.onError = fn
+	m.mu.Unlock()
+	return m
+}
+
+// Close 停止 watcher goroutine 和 Async 启动的 worker 池，之后的文件变更不会
+// 再被感知。
+func (m *fileManager[T]) Close() error {
+	m.closeOnce.Do(func() { close(m.closeCh) })
+	m.subs.close()
+	return nil
+}
+
+// Watch 监听 path 所在目录（而不是文件本身），这样编辑器常见的"写临时文件再
+// rename 覆盖"式保存也能被捕获到。文件事件会被去抖，避免一次保存触发多次
+// 重载。
+func (m *fileManager[T]) Watch() FileManager[T] {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		m.reportError(errorx.Typedf(errorx.ErrTypeInternal, ErrCodeFileWatch, "config: create watcher for %s: %v", m.path, err))
+		return m
+	}
+	if err := watcher.Add(filepath.Dir(m.path)); err != nil {
+		watcher.Close()
+		m.reportError(errorx.Typedf(errorx.ErrTypeInternal, ErrCodeFileWatch, "config: watch %s: %v", m.path, err))
+		return m
+	}
+
+	m.mu.Lock()
+	m.watcher = watcher
+	m.mu.Unlock()
+
+	go m.watchLoop(watcher)
+	return m
+}
+
+func (m *fileManager[T]) watchLoop(watcher *fsnotify.Watcher) {
+	var timer *time.Timer
+	var timerCh <-chan time.Time
+
+	for {
+		select {
+		case <-m.closeCh:
+			watcher.Close()
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(m.path) {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(m.opts.debounce)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(m.opts.debounce)
+			}
+			timerCh = timer.C
+		case <-timerCh:
+			timerCh = nil
+			if err := m.reload(); err != nil {
+				m.reportError(err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			m.reportError(errorx.Typedf(errorx.ErrTypeInternal, ErrCodeFileWatch, "config: watch %s: %v", m.path, err))
+		}
+	}
+}
+
+// reload 重新读取并解码 path，跑一遍校验/迁移管线，成功后更新缓存、记录一条
+// 历史快照并通知订阅者。
+func (m *fileManager[T]) reload() errorx.Error {
+	raw, err := os.ReadFile(m.path)
+	if err != nil {
+		return errorx.Typedf(errorx.ErrTypeNotFound, ErrCodeFileRead, "config: read %s: %v", m.path, err)
+	}
+
+	var decoded T
+	if err := m.opts.codec.Decode(raw, &decoded); err != nil {
+		return errorx.Typedf(errorx.ErrTypeInvalid, ErrCodeFileDecode, "config: decode %s: %v", m.path, err)
+	}
+
+	m.mu.RLock()
+	old := m.data
+	m.mu.RUnlock()
+
+	result, pipeErr := m.pipe.run(old, decoded)
+	if pipeErr != nil {
+		return pipeErr
+	}
+
+	m.mu.Lock()
+	m.data = result
+	m.mu.Unlock()
+
+	version := m.hist.record(result, "reload")
+	m.writeHistorySnapshot(version, result)
+	m.subs.notify(result)
+	return nil
+}
+
+func (m *fileManager[T]) reportError(err errorx.Error) {
+	m.mu.RLock()
+	onError := m.onError
+	m.mu.RUnlock()
+	if onError != nil {
+		onError(err)
+	}
+}
+
+// writeHistorySnapshot 把这个版本序列化写入 path 同目录下的 .history/ 子
+// 目录，文件名按版本号补零，天然按字典序排列；随后按 History 容量上限清理掉
+// 更旧的文件，这样磁盘上的历史记录和内存里的环形缓冲区条数保持一致。
+func (m *fileManager[T]) writeHistorySnapshot(version uint64, value T) {
+	dir := filepath.Join(filepath.Dir(m.path), ".history")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	payload, err := m.opts.codec.Encode(value)
+	if err != nil {
+		return
+	}
+	name := fmt.Sprintf("%020d%s", version, filepath.Ext(m.path))
+	_ = writeFileAtomic(filepath.Join(dir, name), payload)
+	m.pruneHistoryDir(dir)
+}
+
+func (m *fileManager[T]) pruneHistoryDir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	limit := m.hist.currentSize()
+	if len(entries) <= limit {
+		return
+	}
+	for _, e := range entries[:len(entries)-limit] {
+		os.Remove(filepath.Join(dir, e.Name()))
+	}
+}
+
+// writeFileAtomic 把 data 写入一个与 path 同目录的临时文件，再 rename 覆盖
+// path，使并发的读者/watcher 永远只能看到完整的旧内容或完整的新内容。
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang-question/errorx"
+)
+
+type fileTestData struct {
+	Count int
+}
+
+func TestFileManagerInitDataWritesFileAndSkipsWhenNotZero(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	m := File[fileTestData](path)
+	m.InitData(fileTestData{Count: 1})
+
+	if got := m.Get(); got.Count != 1 {
+		t.Fatalf("expected Count 1 after InitData, got %d", got.Count)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected InitData to write %s: %v", path, err)
+	}
+
+	m.InitData(fileTestData{Count: 99})
+	if got := m.Get(); got.Count != 1 {
+		t.Fatalf("expected InitData to be a no-op once data is non-zero, got %d", got.Count)
+	}
+}
+
+func TestFileManagerUpdatePersistsAndRejectsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	m := File[fileTestData](path).
+		WithValidator(func(d fileTestData) errorx.Error {
+			if d.Count < 0 {
+				return errorx.C(1, "count must be non-negative")
+			}
+			return nil
+		})
+	m.InitData(fileTestData{Count: 0})
+
+	if err := m.Update(fileTestData{Count: 5}); err != nil {
+		t.Fatalf("unexpected error updating to a valid value: %+v", err)
+	}
+	if got := m.Get(); got.Count != 5 {
+		t.Fatalf("expected Count 5 after Update, got %d", got.Count)
+	}
+
+	if err := m.Update(fileTestData{Count: -1}); err == nil {
+		t.Fatal("expected Update to reject a negative count")
+	}
+	if got := m.Get(); got.Count != 5 {
+		t.Fatalf("expected rejected Update to leave the stored value unchanged, got %d", got.Count)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	if string(raw) == "" {
+		t.Fatal("expected the config file to contain the persisted value")
+	}
+}
+
+func TestFileManagerRollbackSkipsMigrators(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	m := File[fileTestData](path).
+		WithMigrator(func(old, new fileTestData) (fileTestData, errorx.Error) {
+			new.Count++
+			return new, nil
+		})
+	m.InitData(fileTestData{Count: 0})
+
+	if err := m.Update(fileTestData{Count: 10}); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if got := m.Get(); got.Count != 11 {
+		t.Fatalf("expected the migrator to bump Count to 11, got %d", got.Count)
+	}
+
+	hist := m.History(0)
+	version := hist[len(hist)-1].Version
+
+	if err := m.Update(fileTestData{Count: 20}); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if got := m.Get(); got.Count != 21 {
+		t.Fatalf("expected the migrator to bump Count to 21, got %d", got.Count)
+	}
+
+	if err := m.Rollback(version); err != nil {
+		t.Fatalf("unexpected error rolling back: %+v", err)
+	}
+	if got := m.Get(); got.Count != 11 {
+		t.Fatalf("expected Rollback to restore the snapshot value 11 without re-running the migrator, got %d", got.Count)
+	}
+}
+
+func TestFileManagerWatchPicksUpExternalChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	m := File[fileTestData](path)
+	m.InitData(fileTestData{Count: 0})
+	m.Watch()
+	defer m.Close()
+
+	changed := make(chan fileTestData, 1)
+	m.OnChange(func(d fileTestData) { changed <- d })
+
+	if err := os.WriteFile(path, []byte(`{"Count":7}`), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+
+	select {
+	case got := <-changed:
+		if got.Count != 7 {
+			t.Fatalf("expected the reloaded value to have Count 7, got %d", got.Count)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for Watch to pick up the external change")
+	}
+}
+
+func TestFileManagerCloseStopsWatchAndAsyncWorkers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	m := File[fileTestData](path).Async(2)
+	m.InitData(fileTestData{Count: 0})
+	m.Watch()
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %+v", err)
+	}
+	// Close 之后再调用一次应当仍然安全（closeOnce/subs.close 都是幂等的）。
+	if err := m.Close(); err != nil {
+		t.Fatalf("unexpected error on second Close: %+v", err)
+	}
+}
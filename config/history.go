@@ -0,0 +1,131 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultHistorySize = 16
+
+// Snapshot 记录一次 Update/Rollback/InitData（以及文件后端的 reload）产生的
+// 结果，Source 标记这次写入是怎么发生的（"init"、"update"、"rollback"、
+// "reload" 等），便于审计。
+type Snapshot[T any] struct {
+	Version uint64
+	At      time.Time
+	Value   T
+	Source  string
+}
+
+// history 维护一个有界的 Snapshot 环形缓冲区，版本号单调递增，被 local/etcd/
+// file 三个后端共用。etcd 后端需要在写入 etcd 之前就知道这次写入会被分配到
+// 哪个版本号（因为版本号要和值一起编码进 envelope），所以 reserve/append 是
+// 分开的两步；本地和文件后端可以用一步到位的 record。
+type history[T any] struct {
+	mu      sync.Mutex
+	size    int
+	nextVer uint64
+	entries []Snapshot[T]
+}
+
+func newHistory[T any](size int) *history[T] {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &history[T]{size: size}
+}
+
+// setSize 调整环形缓冲区的容量，如果当前条目数超过新容量，会丢弃最旧的。
+func (h *history[T]) setSize(size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	h.size = size
+	if len(h.entries) > h.size {
+		h.entries = h.entries[len(h.entries)-h.size:]
+	}
+}
+
+func (h *history[T]) currentSize() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.size
+}
+
+// reserve 分配并返回下一个单调递增的版本号，不会把快照写入缓冲区 —— 调用方
+// 在确认写入真正生效之后再调用 append。
+func (h *history[T]) reserve() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextVer++
+	return h.nextVer
+}
+
+// append 把一条已经确定版本号的 Snapshot 追加到环形缓冲区，超出容量时丢弃
+// 最旧的一条。
+func (h *history[T]) append(version uint64, value T, source string, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if version > h.nextVer {
+		h.nextVer = version
+	}
+	h.entries = append(h.entries, Snapshot[T]{Version: version, At: at, Value: value, Source: source})
+	if len(h.entries) > h.size {
+		h.entries = h.entries[len(h.entries)-h.size:]
+	}
+}
+
+// record 是 reserve+append 的一步到位版本，供总是同步成功的后端（local、
+// file）使用。
+func (h *history[T]) record(value T, source string) uint64 {
+	version := h.reserve()
+	h.append(version, value, source, time.Now())
+	return version
+}
+
+// recordIfNew 只在这个版本号还没被记录过时才追加一条 Snapshot，用于 etcd
+// watcher 观察到一个变更事件时去重 —— 这个变更很可能就是本进程自己刚刚写入、
+// 又通过 watch 通道回显回来的。
+func (h *history[T]) recordIfNew(version uint64, value T, source string, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range h.entries {
+		if e.Version == version {
+			return
+		}
+	}
+	if version > h.nextVer {
+		h.nextVer = version
+	}
+	h.entries = append(h.entries, Snapshot[T]{Version: version, At: at, Value: value, Source: source})
+	if len(h.entries) > h.size {
+		h.entries = h.entries[len(h.entries)-h.size:]
+	}
+}
+
+// list 返回最近 n 条 Snapshot，按从旧到新排列；n <= 0 或者大于已有条目数时
+// 返回全部。
+func (h *history[T]) list(n int) []Snapshot[T] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n <= 0 || n > len(h.entries) {
+		n = len(h.entries)
+	}
+	out := make([]Snapshot[T], n)
+	copy(out, h.entries[len(h.entries)-n:])
+	return out
+}
+
+// find 按版本号查找一条 Snapshot。
+func (h *history[T]) find(version uint64) (Snapshot[T], bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range h.entries {
+		if e.Version == version {
+			return e, true
+		}
+	}
+	return Snapshot[T]{}, false
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryRecordAssignsMonotonicVersions(t *testing.T) {
+	h := newHistory[int](16)
+	v1 := h.record(1, "init")
+	v2 := h.record(2, "update")
+	if v2 <= v1 {
+		t.Fatalf("expected version to increase, got v1=%d v2=%d", v1, v2)
+	}
+}
+
+func TestHistoryRingBufferDropsOldest(t *testing.T) {
+	h := newHistory[int](2)
+	h.record(1, "a")
+	h.record(2, "b")
+	h.record(3, "c")
+
+	got := h.list(0)
+	if len(got) != 2 {
+		t.Fatalf("expected capacity of 2 entries to be kept, got %d", len(got))
+	}
+	if got[0].Value != 2 || got[1].Value != 3 {
+		t.Fatalf("expected the oldest entry to be evicted, got %+v", got)
+	}
+}
+
+func TestHistorySetSizeTruncatesExistingEntries(t *testing.T) {
+	h := newHistory[int](16)
+	h.record(1, "a")
+	h.record(2, "b")
+	h.record(3, "c")
+
+	h.setSize(1)
+
+	got := h.list(0)
+	if len(got) != 1 || got[0].Value != 3 {
+		t.Fatalf("expected only the newest entry to survive setSize(1), got %+v", got)
+	}
+}
+
+func TestHistoryFindByVersion(t *testing.T) {
+	h := newHistory[int](16)
+	h.record(1, "a")
+	v2 := h.record(2, "b")
+
+	snap, ok := h.find(v2)
+	if !ok || snap.Value != 2 {
+		t.Fatalf("expected to find version %d with value 2, got %+v ok=%v", v2, snap, ok)
+	}
+
+	if _, ok := h.find(9999); ok {
+		t.Fatal("expected lookup of an unknown version to fail")
+	}
+}
+
+func TestHistoryListReturnsMostRecentN(t *testing.T) {
+	h := newHistory[int](16)
+	h.record(1, "a")
+	h.record(2, "b")
+	h.record(3, "c")
+
+	got := h.list(2)
+	if len(got) != 2 || got[0].Value != 2 || got[1].Value != 3 {
+		t.Fatalf("expected the 2 most recent entries in order, got %+v", got)
+	}
+}
+
+func TestHistoryRecordIfNewDedupesByVersion(t *testing.T) {
+	h := newHistory[int](16)
+	version := h.reserve()
+	at := time.Now()
+
+	h.recordIfNew(version, 1, "watch", at)
+	h.recordIfNew(version, 2, "watch-echo", at)
+
+	got := h.list(0)
+	if len(got) != 1 {
+		t.Fatalf("expected the echoed duplicate version to be ignored, got %+v", got)
+	}
+	if got[0].Value != 1 || got[0].Source != "watch" {
+		t.Fatalf("expected the first recorded snapshot to win, got %+v", got[0])
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"sync"
+
+	"golang-question/errorx"
+)
+
+// Validator 在 Update/InitData 写入新值之前对其做校验，返回非 nil 的 errorx.Error
+// 会中止写入，已存储的值保持不变。
+type Validator[T any] func(T) errorx.Error
+
+// Migrator 在校验通过之后对新值做转换（例如补全默认字段、做版本迁移），
+// old 是写入前的当前值，new 是校验通过的候选值，返回的值才是最终被写入的值。
+type Migrator[T any] func(old, new T) (T, errorx.Error)
+
+// pipeline 按注册顺序依次运行 validators，再依次运行 migrators。
+// 它被 localManager 和 etcdManager 共用，保证两个后端对"校验 -> 迁移"的语义一致。
+type pipeline[T any] struct {
+	mu         sync.Mutex
+	validators []Validator[T]
+	migrators  []Migrator[T]
+}
+
+func newPipeline[T any]() *pipeline[T] {
+	return &pipeline[T]{}
+}
+
+func (p *pipeline[T]) addValidator(v Validator[T]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.validators = append(p.validators, v)
+}
+
+func (p *pipeline[T]) addMigrator(m Migrator[T]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.migrators = append(p.migrators, m)
+}
+
+// run 先用 newData 依次调用每个 validator，任意一个失败就直接返回该错误，
+// 不会执行任何 migrator。全部通过后，依次调用每个 migrator 对值做转换，
+// 返回最终应当被写入的值。
+func (p *pipeline[T]) run(old, newData T) (T, errorx.Error) {
+	if err := p.validate(newData); err != nil {
+		return newData, err
+	}
+
+	p.mu.Lock()
+	migrators := make([]Migrator[T], len(p.migrators))
+	copy(migrators, p.migrators)
+	p.mu.Unlock()
+
+	result := newData
+	for _, migrate := range migrators {
+		migrated, err := migrate(old, result)
+		if err != nil {
+			return newData, err
+		}
+		result = migrated
+	}
+	return result, nil
+}
+
+// validate 依次调用每个已注册的 validator，不会运行任何 migrator。validator
+// 返回的错误如果不是 ErrTypeInvalid，会被转换成同 code、同消息的
+// ErrTypeInvalid 错误再返回。
+// 供 Rollback 使用：历史快照里的值在第一次写入时已经跑过迁移了，回滚只需要
+// 确认这个值仍然合法，不应该再对它做一次迁移。
+func (p *pipeline[T]) validate(newData T) errorx.Error {
+	p.mu.Lock()
+	validators := make([]Validator[T], len(p.validators))
+	copy(validators, p.validators)
+	p.mu.Unlock()
+
+	for _, validate := range validators {
+		if err := validate(newData); err != nil {
+			if err.Type() != errorx.ErrTypeInvalid {
+				return errorx.Typedf(errorx.ErrTypeInvalid, err.Code(), "%s", err.Error())
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"testing"
+
+	"golang-question/errorx"
+)
+
+func TestPipelineRunPassesThroughWithNoStages(t *testing.T) {
+	p := newPipeline[int]()
+	result, err := p.run(0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if result != 5 {
+		t.Fatalf("expected 5, got %d", result)
+	}
+}
+
+func TestPipelineRunValidatorRejectsAndSkipsMigrators(t *testing.T) {
+	p := newPipeline[int]()
+	p.addValidator(func(v int) errorx.Error {
+		if v < 0 {
+			return errorx.C(1, "negative value")
+		}
+		return nil
+	})
+	migratorRan := false
+	p.addMigrator(func(old, new int) (int, errorx.Error) {
+		migratorRan = true
+		return new, nil
+	})
+
+	_, err := p.run(0, -1)
+	if err == nil {
+		t.Fatal("expected validator to reject negative value")
+	}
+	if migratorRan {
+		t.Fatal("migrator should not run once a validator rejects the value")
+	}
+}
+
+func TestPipelineRunValidatorsRunInOrder(t *testing.T) {
+	p := newPipeline[int]()
+	var order []int
+	p.addValidator(func(int) errorx.Error { order = append(order, 1); return nil })
+	p.addValidator(func(int) errorx.Error { order = append(order, 2); return errorx.C(1, "stop") })
+	p.addValidator(func(int) errorx.Error { order = append(order, 3); return nil })
+
+	if _, err := p.run(0, 1); err == nil {
+		t.Fatal("expected the second validator to reject")
+	}
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Fatalf("expected validators 1 then 2 to run and stop before 3, got %v", order)
+	}
+}
+
+func TestPipelineRunMigratorsChainInOrder(t *testing.T) {
+	p := newPipeline[int]()
+	p.addMigrator(func(old, new int) (int, errorx.Error) { return new + 1, nil })
+	p.addMigrator(func(old, new int) (int, errorx.Error) { return new * 2, nil })
+
+	result, err := p.run(0, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if result != 4 {
+		t.Fatalf("expected (1+1)*2=4, got %d", result)
+	}
+}
+
+func TestPipelineRunMigratorErrorStopsChain(t *testing.T) {
+	p := newPipeline[int]()
+	secondRan := false
+	p.addMigrator(func(old, new int) (int, errorx.Error) { return new, errorx.C(1, "boom") })
+	p.addMigrator(func(old, new int) (int, errorx.Error) { secondRan = true; return new, nil })
+
+	_, err := p.run(0, 1)
+	if err == nil {
+		t.Fatal("expected the first migrator's error to be returned")
+	}
+	if secondRan {
+		t.Fatal("subsequent migrators should not run once one fails")
+	}
+}
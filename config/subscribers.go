@@ -0,0 +1,144 @@
+package config
+
+import (
+	"sync"
+
+	"golang-question/errorx"
+)
+
+const (
+	// ErrCodeCallbackPanic OnChange 回调执行时发生 panic
+	ErrCodeCallbackPanic = 2005
+)
+
+// subscribers 管理一组通过 OnChange 注册的回调。
+// 每个回调拥有一个唯一的 token，cancel 只会移除自己的 token 对应的条目，
+// 不会影响其它订阅者。notify 在持有锁的情况下只做一次快照，真正调用回调
+// 时已经释放了锁，这样一个慢回调或者 panic 不会拖住 Get/Update。
+//
+// 默认情况下回调是同步调用的；setAsync 可以切换为通过一个有界 worker 池
+// 异步调用，这些 worker goroutine 会一直存活到 close 被调用为止，所以持有
+// subscribers 的 Manager 在自己的 Close/Stop 里必须调用一次 close，否则每个
+// 开启过 Async 的 Manager 都会永久泄漏 workers 个 goroutine。两种模式下，
+// 回调内部的 panic 都会被恢复，并作为 ErrTypeInternal 错误写入 errCh，由
+// Manager.Errors() 暴露给调用方。
+type subscribers[T any] struct {
+	mu        sync.Mutex
+	nextToken uint64
+	callbacks map[uint64]func(T)
+
+	async bool
+	work  chan func()
+
+	stopCh    chan struct{}
+	closeOnce sync.Once
+
+	errCh chan errorx.Error
+}
+
+func newSubscribers[T any]() *subscribers[T] {
+	return &subscribers[T]{
+		callbacks: make(map[uint64]func(T)),
+		stopCh:    make(chan struct{}),
+		errCh:     make(chan errorx.Error, 16),
+	}
+}
+
+// add 注册一个新的回调，返回的 cancel 只移除这一个回调。
+func (s *subscribers[T]) add(callback func(T)) (cancel func()) {
+	s.mu.Lock()
+	token := s.nextToken
+	s.nextToken++
+	s.callbacks[token] = callback
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		delete(s.callbacks, token)
+		s.mu.Unlock()
+	}
+}
+
+// setAsync 把回调的分发方式切换为通过 workers 个 goroutine 组成的 worker 池异步执行。
+// 重复调用只有第一次生效，workers < 1 时按 1 处理。
+func (s *subscribers[T]) setAsync(workers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.async {
+		return
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	s.async = true
+	s.work = make(chan func(), workers*2)
+	for i := 0; i < workers; i++ {
+		go s.runWorker()
+	}
+}
+
+func (s *subscribers[T]) runWorker() {
+	for {
+		select {
+		case call := <-s.work:
+			call()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// close 停止 setAsync 启动的所有 worker goroutine；不开启 Async 时调用也是
+// 安全的（此时只是关闭一个没有人在等待的 channel）。重复调用只有第一次生效。
+func (s *subscribers[T]) close() {
+	s.closeOnce.Do(func() { close(s.stopCh) })
+}
+
+// notify 把 newData 分发给当前所有订阅者。订阅者列表在持锁状态下快照，
+// 实际调用发生在锁外，因此新增/取消订阅不会被阻塞。
+func (s *subscribers[T]) notify(newData T) {
+	s.mu.Lock()
+	cbs := make([]func(T), 0, len(s.callbacks))
+	for _, cb := range s.callbacks {
+		cbs = append(cbs, cb)
+	}
+	async := s.async
+	work := s.work
+	s.mu.Unlock()
+
+	for _, cb := range cbs {
+		cb := cb
+		call := func() {
+			defer s.recoverAndReport()
+			cb(newData)
+		}
+		if async {
+			work <- call
+		} else {
+			call()
+		}
+	}
+}
+
+func (s *subscribers[T]) recoverAndReport() {
+	if r := recover(); r != nil {
+		err := errorx.Typedf(errorx.ErrTypeInternal, ErrCodeCallbackPanic, "config: OnChange callback panicked: %v", r)
+		select {
+		case s.errCh <- err:
+		default:
+			// 错误 channel 已满，丢弃最旧的消费者还未读取的错误，避免阻塞通知。
+			select {
+			case <-s.errCh:
+			default:
+			}
+			select {
+			case s.errCh <- err:
+			default:
+			}
+		}
+	}
+}
+
+func (s *subscribers[T]) errors() <-chan errorx.Error {
+	return s.errCh
+}
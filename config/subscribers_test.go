@@ -0,0 +1,87 @@
+package config
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubscribersNotify(t *testing.T) {
+	s := newSubscribers[int]()
+	var got []int
+	var mu sync.Mutex
+	s.add(func(v int) {
+		mu.Lock()
+		got = append(got, v)
+		mu.Unlock()
+	})
+	s.add(func(v int) {
+		mu.Lock()
+		got = append(got, v*10)
+		mu.Unlock()
+	})
+
+	s.notify(1)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 callbacks to run, got %d: %v", len(got), got)
+	}
+}
+
+func TestSubscribersCancelOnlyRemovesOwnCallback(t *testing.T) {
+	s := newSubscribers[int]()
+	var calledA, calledB bool
+	cancelA := s.add(func(int) { calledA = true })
+	s.add(func(int) { calledB = true })
+
+	cancelA()
+	s.notify(1)
+
+	if calledA {
+		t.Fatal("cancelled callback should not have been called")
+	}
+	if !calledB {
+		t.Fatal("uncancelled callback should have been called")
+	}
+}
+
+func TestSubscribersRecoversPanicAndReportsError(t *testing.T) {
+	s := newSubscribers[int]()
+	s.add(func(int) { panic("boom") })
+
+	s.notify(1)
+
+	select {
+	case err := <-s.errors():
+		if err.Code() != ErrCodeCallbackPanic {
+			t.Fatalf("expected ErrCodeCallbackPanic, got %d", err.Code())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a panic to be reported on the error channel")
+	}
+}
+
+func TestSubscribersAsyncDeliversToAllCallbacks(t *testing.T) {
+	s := newSubscribers[int]()
+	done := make(chan int, 2)
+	s.add(func(v int) { done <- v })
+	s.add(func(v int) { done <- v * 10 })
+	s.setAsync(2)
+
+	s.notify(3)
+
+	seen := map[int]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case v := <-done:
+			seen[v] = true
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for async callback delivery")
+		}
+	}
+	if !seen[3] || !seen[30] {
+		t.Fatalf("expected both callbacks to have run, got %v", seen)
+	}
+}
@@ -0,0 +1,158 @@
+package errorx
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+)
+
+// ErrCodeUnknown 是未注册错误码的兜底值，Register/MustRegister 不允许覆盖它。
+const ErrCodeUnknown = 999999
+
+// Coder 描述一个注册到 errorx 的错误码：对外暴露的整数 Code、映射的 HTTP 状态码、
+// gRPC 状态码、一段人类可读的描述，以及一个可选的排障/文档链接。
+type Coder interface {
+	Code() int
+	HTTPStatus() int
+	GRPCStatus() codes.Code
+	String() string
+	Reference() string
+}
+
+type baseCoder struct {
+	code       int
+	httpStatus int
+	grpcStatus codes.Code
+	msg        string
+	reference  string
+}
+
+func (c *baseCoder) Code() int              { return c.code }
+func (c *baseCoder) HTTPStatus() int        { return c.httpStatus }
+func (c *baseCoder) GRPCStatus() codes.Code { return c.grpcStatus }
+func (c *baseCoder) String() string         { return c.msg }
+func (c *baseCoder) Reference() string      { return c.reference }
+
+// NewCoder 构造一个开箱即用的 Coder，大多数调用方不需要自己实现 Coder 接口。
+func NewCoder(code int, httpStatus int, grpcStatus codes.Code, msg string, reference string) Coder {
+	return &baseCoder{
+		code:       code,
+		httpStatus: httpStatus,
+		grpcStatus: grpcStatus,
+		msg:        msg,
+		reference:  reference,
+	}
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[int]Coder{
+		ErrCodeUnknown: NewCoder(ErrCodeUnknown, http.StatusInternalServerError, codes.Unknown, "internal server error", ""),
+	}
+)
+
+// Register 把 coder 注册到全局表中。code 冲突（包括试图覆盖 ErrCodeUnknown）
+// 时不做任何修改并返回 false。
+func Register(coder Coder) bool {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[coder.Code()]; exists {
+		return false
+	}
+	registry[coder.Code()] = coder
+	return true
+}
+
+// MustRegister 与 Register 类似，但注册失败时 panic，适合在 init() 中调用。
+func MustRegister(coder Coder) {
+	if !Register(coder) {
+		panic(fmt.Sprintf("errorx: code %d already registered", coder.Code()))
+	}
+}
+
+// lookupCoder 返回 code 对应的 Coder，未注册时返回兜底的 unknown coder。
+func lookupCoder(code int) Coder {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if c, ok := registry[code]; ok {
+		return c
+	}
+	return registry[ErrCodeUnknown]
+}
+
+// findCoded 沿着 err 的 cause 链查找第一个带有非零 code 的 *customError。
+// 像 Wrap/Join 这样本身不携带 code 的包装层会被穿透继续往下找，既支持单
+// cause 的 Unwrap() error，也支持 errors.Join/errorx.Join 产生的
+// Unwrap() []error —— 否则 errorx.Join(e1WithCode, e2) 会在外层 Join 产生的
+// 无码包装处就停下来，找不到 e1 真正携带的 code。
+func findCoded(err error) (*customError, bool) {
+	if err == nil {
+		return nil, false
+	}
+	if ce, ok := err.(*customError); ok {
+		if ce.code != 0 {
+			return ce, true
+		}
+		return findCoded(ce.cause)
+	}
+	if x, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range x.Unwrap() {
+			if ce, ok := findCoded(e); ok {
+				return ce, true
+			}
+		}
+		return nil, false
+	}
+	if x, ok := err.(interface{ Unwrap() error }); ok {
+		return findCoded(x.Unwrap())
+	}
+	return nil, false
+}
+
+// ParseCoder 沿着 err 的 cause 链查找第一个带有非零 code 的 *customError，
+// 返回它的 code 在注册表里对应的 Coder；找不到 errorx 错误、所有 code 都是
+// 零值，或者 code 未注册时都返回兜底的 unknown coder。
+func ParseCoder(err error) Coder {
+	ce, ok := findCoded(err)
+	if !ok {
+		return lookupCoder(ErrCodeUnknown)
+	}
+	return lookupCoder(ce.code)
+}
+
+// HTTPStatus 返回 err 对应的 HTTP 状态码，方便 HTTP handler 不需要知道错误码表
+// 就能把任意 errorx.Error 翻译成正确的响应状态。
+func HTTPStatus(err error) int {
+	return ParseCoder(err).HTTPStatus()
+}
+
+// GRPCStatus 返回 err 对应的 gRPC 状态码，用法同 HTTPStatus。
+func GRPCStatus(err error) codes.Code {
+	return ParseCoder(err).GRPCStatus()
+}
+
+// WithCode 用一个已注册的 code 包装一个已有的 error。如果 err 本身就是
+// errorx.Error，会保留它原有的 stack 和 errType，只替换 code；否则会像 Wrap
+// 一样在当前位置捕获一个新的 stack。
+func WithCode(code int, err error) Error {
+	if err == nil {
+		return nil
+	}
+	if ce, ok := err.(*customError); ok {
+		return &customError{
+			msg:     ce.msg,
+			code:    code,
+			errType: ce.errType,
+			stack:   ce.stack,
+			cause:   ce.cause,
+		}
+	}
+	return &customError{
+		msg:   err.Error(),
+		code:  code,
+		cause: err,
+		stack: captureStack(),
+	}
+}
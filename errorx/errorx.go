@@ -1,7 +1,9 @@
 package errorx
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"runtime"
 )
 
@@ -68,8 +70,101 @@ func (e *customError) Stack() Stack {
 	return e.stack
 }
 
+// Is 让 errors.Is 能够识别 errorx 的 ErrType/Code 语义：当 target 也是一个
+// *customError 时，按 target 上设置的 errType/code 去匹配 e，而不要求消息
+// 文本相同。target 只要求其中至少一项非零，这样调用方可以只关心类型
+// （如 errors.Is(err, errorx.Typed(errorx.ErrTypeConflict, 0, ""))）或只关心
+// 错误码，两种都匹配时才算命中。
+func (e *customError) Is(target error) bool {
+	t, ok := target.(*customError)
+	if !ok {
+		return false
+	}
+	if t.errType == "" && t.code == 0 {
+		return false
+	}
+	if t.errType != "" && t.errType != e.errType {
+		return false
+	}
+	if t.code != 0 && t.code != e.code {
+		return false
+	}
+	return true
+}
+
+// As 让 errors.As 可以把 err 的 cause 链中的某一环提取为 *customError。
+func (e *customError) As(target interface{}) bool {
+	t, ok := target.(**customError)
+	if !ok {
+		return false
+	}
+	*t = e
+	return true
+}
+
+// IsType 沿着 err 的 cause 链查找，判断是否存在一个 errType 等于 t 的
+// errorx 错误。和 errors.Is 一样，既支持单 cause 的 Unwrap() error，也支持
+// errors.Join/errorx.Join 产生的 Unwrap() []error，否则 Join 出来的错误会在
+// 第一层就因为命中不了 errType 而被误判为不包含 t。
+func IsType(err error, t ErrType) bool {
+	if err == nil {
+		return false
+	}
+	if ce, ok := err.(*customError); ok && ce.errType == t {
+		return true
+	}
+	if x, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range x.Unwrap() {
+			if IsType(e, t) {
+				return true
+			}
+		}
+		return false
+	}
+	if x, ok := err.(interface{ Unwrap() error }); ok {
+		return IsType(x.Unwrap(), t)
+	}
+	return false
+}
+
+// Format 实现 fmt.Formatter。%s/%v 只打印错误信息；%+v 在此基础上依次打印
+// Stack 中的每一帧（"name\n\tfile:line"），并递归打印 cause 链，cause 每多
+// 包一层就多缩进一级，方便在日志里看出是谁包装了谁。
 func (e *customError) Format(f fmt.State, c rune) {
-	fmt.Fprintf(f, "%s", e.Error())
+	switch c {
+	case 'v':
+		if f.Flag('+') {
+			e.formatVerbose(f, "")
+			return
+		}
+		io.WriteString(f, e.Error())
+	case 's':
+		io.WriteString(f, e.Error())
+	default:
+		io.WriteString(f, e.Error())
+	}
+}
+
+func (e *customError) formatVerbose(w io.Writer, indent string) {
+	io.WriteString(w, indent+e.msg)
+	e.stack.writeTo(w, indent)
+	if e.cause == nil {
+		return
+	}
+	fmt.Fprintf(w, "\n%sCaused by: ", indent)
+	if ce, ok := e.cause.(*customError); ok {
+		ce.formatVerbose(w, indent+"\t")
+		return
+	}
+	io.WriteString(w, e.cause.Error())
+}
+
+// writeTo 把 Stack 中的每一帧格式化为 "name\n\tfile:line" 写入 w，
+// prefix 与调用方当前的缩进保持一致。
+func (s Stack) writeTo(w io.Writer, prefix string) {
+	for _, frame := range s {
+		fmt.Fprintf(w, "\n%s%s\n%s\t%s:%d", prefix, frame.Name, prefix, frame.File, frame.Line)
+	}
 }
 
 // captureStack 函数用于捕获当前调用栈信息并返回为一个 Stack 类型的切片
@@ -152,3 +247,40 @@ func Cf(code int, format string, args ...interface{}) Error {
 		stack: captureStack(),
 	}
 }
+
+// Typed 创建一个带有错误类型的自定义错误对象。
+// 调用方可以通过 errType 标记错误的分类（例如 ErrTypeConflict、ErrTypeUnavailable），
+// 便于上层根据类型而非具体错误码做出处理决策。
+func Typed(errType ErrType, code int, msg string) Error {
+	return &customError{
+		msg:     msg,
+		code:    code,
+		errType: errType,
+		stack:   captureStack(),
+	}
+}
+
+// Typedf 与 Typed 类似，但支持格式化错误信息。
+func Typedf(errType ErrType, code int, format string, args ...interface{}) Error {
+	return &customError{
+		msg:     fmt.Sprintf(format, args...),
+		code:    code,
+		errType: errType,
+		stack:   captureStack(),
+	}
+}
+
+// Join 把多个 error 合并为一个 errorx.Error。nil 的 err 会被忽略，
+// 如果 errs 里一个非 nil 的都没有则返回 nil，和 errors.Join 的语义一致。
+// 栈只在 Join 这里捕获一次，而不是重新计算每个 err 各自的栈。
+func Join(errs ...error) Error {
+	joined := errors.Join(errs...)
+	if joined == nil {
+		return nil
+	}
+	return &customError{
+		msg:   joined.Error(),
+		cause: joined,
+		stack: captureStack(),
+	}
+}
@@ -0,0 +1,197 @@
+package errorx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIsMatchesByTypeAndCode(t *testing.T) {
+	err := Typed(ErrTypeConflict, 42, "version conflict")
+
+	if !errors.Is(err, Typed(ErrTypeConflict, 0, "")) {
+		t.Fatal("expected errors.Is to match on type alone")
+	}
+	if !errors.Is(err, Typed("", 42, "")) {
+		t.Fatal("expected errors.Is to match on code alone")
+	}
+	if errors.Is(err, Typed(ErrTypeTimeout, 0, "")) {
+		t.Fatal("expected errors.Is to reject a mismatched type")
+	}
+	if errors.Is(err, Typed("", 0, "")) {
+		t.Fatal("a target with no type and no code should never match")
+	}
+}
+
+func TestAsExtractsCustomError(t *testing.T) {
+	err := New("boom")
+
+	var target Error
+	if !errors.As(err, &target) {
+		t.Fatal("expected errors.As to extract the errorx.Error")
+	}
+	if target.Error() != "boom" {
+		t.Fatalf("expected message %q, got %q", "boom", target.Error())
+	}
+}
+
+func TestIsTypeFindsDirectMatch(t *testing.T) {
+	err := Typed(ErrTypeInvalid, 0, "bad input")
+	if !IsType(err, ErrTypeInvalid) {
+		t.Fatal("expected IsType to find a direct match")
+	}
+	if IsType(err, ErrTypeTimeout) {
+		t.Fatal("expected IsType to reject a mismatched type")
+	}
+}
+
+func TestIsTypeDescendsJoin(t *testing.T) {
+	a := Typed(ErrTypeTimeout, 0, "timed out")
+	b := Typed(ErrTypeInvalid, 0, "bad input")
+	joined := Join(a, b)
+
+	if !IsType(joined, ErrTypeTimeout) {
+		t.Fatal("expected IsType to find a type buried in a Join result")
+	}
+	if !IsType(joined, ErrTypeInvalid) {
+		t.Fatal("expected IsType to find the other branch of the Join result")
+	}
+	if IsType(joined, ErrTypeConflict) {
+		t.Fatal("expected IsType to reject a type that isn't present in either branch")
+	}
+}
+
+func TestIsTypeNilReturnsFalse(t *testing.T) {
+	if IsType(nil, ErrTypeInvalid) {
+		t.Fatal("expected IsType(nil, ...) to be false")
+	}
+}
+
+func TestFormatPlainVerbsOnlyPrintMessage(t *testing.T) {
+	err := New("boom")
+	if got := fmt.Sprintf("%v", err); got != "boom" {
+		t.Fatalf("expected %%v to print just the message, got %q", got)
+	}
+	if got := fmt.Sprintf("%s", err); got != "boom" {
+		t.Fatalf("expected %%s to print just the message, got %q", got)
+	}
+}
+
+func TestFormatVerbosePrintsCauseChainIndented(t *testing.T) {
+	inner := New("inner failure")
+	outer := Wrap(inner)
+
+	got := fmt.Sprintf("%+v", outer)
+	if !strings.Contains(got, "inner failure") {
+		t.Fatalf("expected %%+v output to mention the cause, got %q", got)
+	}
+	if !strings.Contains(got, "Caused by:") {
+		t.Fatalf("expected %%+v output to contain a \"Caused by:\" marker, got %q", got)
+	}
+
+	causeIndex := strings.Index(got, "Caused by:")
+	if causeIndex < 0 {
+		t.Fatalf("expected a \"Caused by:\" marker, got %q", got)
+	}
+	if !strings.Contains(got[causeIndex:], "inner failure") {
+		t.Fatalf("expected the cause message to appear after its \"Caused by:\" marker, got %q", got)
+	}
+	if !strings.Contains(got[causeIndex:], "Caused by: \tinner failure") {
+		t.Fatalf("expected the cause to be indented one level deeper than its wrapper, got %q", got)
+	}
+}
+
+func TestJoinIgnoresNilAndReturnsNilWhenEmpty(t *testing.T) {
+	if err := Join(nil, nil); err != nil {
+		t.Fatalf("expected Join of only nils to return nil, got %+v", err)
+	}
+
+	err := Join(nil, New("first"), nil, New("second"))
+	if err == nil {
+		t.Fatal("expected Join to return a non-nil error")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "first") || !strings.Contains(msg, "second") {
+		t.Fatalf("expected joined message to mention both errors, got %q", msg)
+	}
+}
+
+func TestCoderRegisterRejectsDuplicateCode(t *testing.T) {
+	const code = 1_000_001
+	if !Register(NewCoder(code, 400, 0, "first registration", "")) {
+		t.Fatal("expected the first registration to succeed")
+	}
+	if Register(NewCoder(code, 500, 0, "second registration", "")) {
+		t.Fatal("expected a duplicate code registration to be rejected")
+	}
+}
+
+func TestCoderRegisterRejectsOverridingUnknown(t *testing.T) {
+	if Register(NewCoder(ErrCodeUnknown, 200, 0, "should not be allowed", "")) {
+		t.Fatal("expected registering over ErrCodeUnknown to be rejected")
+	}
+}
+
+func TestMustRegisterPanicsOnDuplicateCode(t *testing.T) {
+	const code = 1_000_002
+	MustRegister(NewCoder(code, 400, 0, "first registration", ""))
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected MustRegister to panic on a duplicate code")
+		}
+	}()
+	MustRegister(NewCoder(code, 500, 0, "second registration", ""))
+}
+
+func TestParseCoderFindsFirstCodeInCauseChain(t *testing.T) {
+	const code = 1_000_003
+	coder := NewCoder(code, 409, 0, "conflict", "")
+	if !Register(coder) {
+		t.Fatalf("expected code %d to register cleanly", code)
+	}
+
+	coded := C(code, "specific failure")
+	wrapped := Wrap(coded)
+
+	got := ParseCoder(wrapped)
+	if got.Code() != code {
+		t.Fatalf("expected ParseCoder to find code %d through the wrap, got %d", code, got.Code())
+	}
+	if HTTPStatus(wrapped) != 409 {
+		t.Fatalf("expected HTTPStatus 409, got %d", HTTPStatus(wrapped))
+	}
+}
+
+func TestParseCoderDescendsJoin(t *testing.T) {
+	const code = 1_000_004
+	if !Register(NewCoder(code, 422, 0, "joined failure", "")) {
+		t.Fatalf("expected code %d to register cleanly", code)
+	}
+
+	joined := Join(New("no code here"), C(code, "this one has a code"))
+	got := ParseCoder(joined)
+	if got.Code() != code {
+		t.Fatalf("expected ParseCoder to find code %d inside the Join result, got %d", code, got.Code())
+	}
+}
+
+func TestParseCoderFallsBackToUnknown(t *testing.T) {
+	got := ParseCoder(errors.New("plain stdlib error"))
+	if got.Code() != ErrCodeUnknown {
+		t.Fatalf("expected the unknown coder for a non-errorx error, got code %d", got.Code())
+	}
+}
+
+func TestWithCodePreservesStackAndType(t *testing.T) {
+	original := Typed(ErrTypeInvalid, 0, "bad input")
+	coded := WithCode(77, original)
+
+	if coded.Code() != 77 {
+		t.Fatalf("expected code 77, got %d", coded.Code())
+	}
+	if coded.Type() != ErrTypeInvalid {
+		t.Fatalf("expected type to be preserved, got %q", coded.Type())
+	}
+}
@@ -26,9 +26,12 @@ type Config struct {
 	Secret Secret `yaml:"secret" json:"secret"`
 }
 
-var conf = config.Local[Config]().Watch().InitData(Config{
-	Secret: "hello world",
-})
+var conf = config.Local[Config]().
+	WithValidator(func(c Config) errorx.Error { return c.Secret.Validate() }).
+	Watch().
+	InitData(Config{
+		Secret: "hello world",
+	})
 
 func main() {
 	// 获取配置中的Secret
@@ -40,12 +43,25 @@ func main() {
 		fmt.Printf("validate error: %+v\n", err)
 	}
 
-	// 更新配置中的Secret
+	// 更新配置中的Secret，这次更新合法，会产生一条新的历史记录
 	if err := conf.Update(Config{Secret: Secret("updated secret")}); err != nil {
 		// 如果更新失败，打印错误信息
 		fmt.Printf("update error: %+v\n", err)
-	} else {
-		// 如果更新成功，打印新的Secret
-		fmt.Printf("Secret updated to: %s\n", conf.Get().Secret)
+		return
+	}
+	fmt.Printf("Secret updated to: %s\n", conf.Get().Secret)
+	lastGoodVersion := conf.History(1)[0].Version
+
+	// 尝试推送一个不合法的值：WithValidator 注册的 Secret.Validate() 会拒绝
+	// 它，已存储的值保持不变。
+	if err := conf.Update(Config{Secret: Secret("short")}); err != nil {
+		fmt.Printf("update error: %+v\n", err)
+
+		// 回滚到上一次已知合法的版本，回滚本身也会产生一条新的历史记录。
+		if err := conf.Rollback(lastGoodVersion); err != nil {
+			fmt.Printf("rollback error: %+v\n", err)
+			return
+		}
+		fmt.Printf("Secret rolled back to: %s\n", conf.Get().Secret)
 	}
 }